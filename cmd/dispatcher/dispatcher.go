@@ -1,16 +1,26 @@
 package dispatcher
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/codegangsta/negroni"
 	restful "github.com/emicklei/go-restful"
+	"github.com/garyburd/redigo/redis"
 	"github.com/m0sth8/cli" // use fork until subcommands will be fixed
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	mgo "gopkg.in/mgo.v2"
 
+	"github.com/bearded-web/bearded/pkg/config"
 	"github.com/bearded-web/bearded/pkg/filters"
 	"github.com/bearded-web/bearded/pkg/manager"
 	"github.com/bearded-web/bearded/pkg/passlib"
@@ -18,6 +28,7 @@ import (
 	"github.com/bearded-web/bearded/services"
 	"github.com/bearded-web/bearded/services/agent"
 	"github.com/bearded-web/bearded/services/auth"
+	"github.com/bearded-web/bearded/services/chatops"
 	"github.com/bearded-web/bearded/services/me"
 	"github.com/bearded-web/bearded/services/plan"
 	"github.com/bearded-web/bearded/services/plugin"
@@ -30,31 +41,49 @@ import (
 var Dispatcher = cli.Command{
 	Name:   "dispatcher",
 	Usage:  "Start Dispatcher",
+	Before: dispatcherBefore,
 	Action: dispatcherAction,
 	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "config",
+			EnvVar: "BEARDED_CONFIG",
+			Usage:  "path to a YAML or JSON configuration file, see pkg/config for the schema",
+		},
+		cli.StringFlag{
+			Name:   "log-level",
+			Value:  "info",
+			EnvVar: "BEARDED_LOG_LEVEL",
+			Usage:  "log level: debug, info, warn, error, fatal, panic",
+		},
+		cli.StringFlag{
+			Name:   "log-format",
+			Value:  "text",
+			EnvVar: "BEARDED_LOG_FORMAT",
+			Usage:  "log format: text, json",
+		},
 		cli.StringFlag{
 			Name:   "bind-addr",
 			Value:  "127.0.0.1:3003",
 			EnvVar: "BEARDED_BIND_ADDR",
-			Usage:  "http address for binding api server",
+			Usage:  "http address for binding api server, overrides bind_addr in --config",
 		},
 		cli.StringFlag{
 			Name:   "mongo-addr",
 			Value:  "127.0.0.1",
 			EnvVar: "BEARDED_MONGO_ADDR",
-			Usage:  MongoUsage,
+			Usage:  MongoUsage + ", overrides mongo.addr in --config",
 		},
 		cli.StringFlag{
 			Name:   "mongo-db",
 			Value:  "bearded",
 			EnvVar: "BEARDED_MONGO_DB",
-			Usage:  "Mongodb database",
+			Usage:  "Mongodb database, overrides mongo.db in --config",
 		},
 		cli.StringFlag{
 			Name:   "frontend",
 			Value:  "../frontend/dist/",
 			EnvVar: "BEARDED_FRONTEND",
-			Usage:  "path to frontend to serve static",
+			Usage:  "path to frontend to serve static, overrides frontend in --config",
 		},
 		cli.BoolFlag{
 			Name:   "frontend-off",
@@ -65,6 +94,33 @@ var Dispatcher = cli.Command{
 			Name:	"with-agent",
 			Usage:	"Run agent inside the dispatcher",
 		},
+		cli.StringFlag{
+			Name:   "scheduler-backend",
+			Value:  "memory",
+			EnvVar: "BEARDED_SCHEDULER_BACKEND",
+			Usage:  "task queue backend for agent polling: memory, mongo or redis, overrides scheduler.backend in --config",
+		},
+		cli.StringFlag{
+			Name:   "slack-signing-secret",
+			EnvVar: "BEARDED_SLACK_SIGNING_SECRET",
+			Usage:  "Slack signing secret for verifying /bearded slash commands, overrides chatops.slack_signing_secret in --config",
+		},
+		cli.StringFlag{
+			Name:   "chatops-base-url",
+			EnvVar: "BEARDED_CHATOPS_BASE_URL",
+			Usage:  "public base url used in chatops responses, overrides chatops.base_url in --config",
+		},
+		cli.StringFlag{
+			Name:   "shutdown-timeout",
+			Value:  "15s",
+			EnvVar: "BEARDED_SHUTDOWN_TIMEOUT",
+			Usage:  "grace period for in-flight requests during shutdown, overrides shutdown_timeout in --config",
+		},
+		cli.StringFlag{
+			Name:   "metrics-addr",
+			EnvVar: "BEARDED_METRICS_ADDR",
+			Usage:  "address to serve Prometheus /metrics on, e.g. 127.0.0.1:9090; disabled if empty, overrides metrics_addr in --config",
+		},
 	},
 }
 
@@ -72,20 +128,43 @@ func init() {
 	Dispatcher.Flags = append(Dispatcher.Flags, swaggerFlags()...)
 }
 
-func initServices(wsContainer *restful.Container, db *mgo.Database) error {
+// dispatcherBefore sets the logrus level and formatter from --log-level
+// and --log-format before dispatcherAction runs, so every line logged
+// during startup already honours them.
+func dispatcherBefore(ctx *cli.Context) error {
+	level, err := logrus.ParseLevel(ctx.String("log-level"))
+	if err != nil {
+		return fmt.Errorf("invalid --log-level %q: %v", ctx.String("log-level"), err)
+	}
+	logrus.SetLevel(level)
+
+	switch ctx.String("log-format") {
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	default:
+		return fmt.Errorf("invalid --log-format %q, must be text or json", ctx.String("log-format"))
+	}
+	return nil
+}
+
+func initServices(wsContainer *restful.Container, db *mgo.Database, backend scheduler.Backend, chatopsOpts chatops.Opts) (*manager.Manager, error) {
 	// manager
 	mgr := manager.New(db)
 	if err := mgr.Init(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// password manager for generation and verification passwords
 	passCtx := passlib.NewContext()
 
-	sch := scheduler.NewMemoryScheduler(mgr.Copy())
-
-	// services
-	base := services.New(mgr, passCtx, sch)
+	// All scan dispatch, including what used to go through a hardcoded
+	// in-process memory scheduler, now goes through the single
+	// configurable backend: with the mongo or redis backend, that's what
+	// lets scans survive a dispatcher restart and be shared correctly
+	// across replicas.
+	base := services.New(mgr, passCtx, backend)
 	all := []services.ServiceInterface{
 		auth.New(base),
 		plugin.New(base),
@@ -96,12 +175,13 @@ func initServices(wsContainer *restful.Container, db *mgo.Database) error {
 		scan.New(base),
 		me.New(base),
 		agent.New(base),
+		chatops.New(base, chatopsOpts, backend),
 	}
 
 	// initialize services
 	for _, s := range all {
 		if err := s.Init(); err != nil {
-			return err
+			return nil, err
 		}
 	}
 	// register services in container
@@ -109,7 +189,7 @@ func initServices(wsContainer *restful.Container, db *mgo.Database) error {
 		s.Register(wsContainer)
 	}
 
-	return nil
+	return mgr, nil
 }
 
 //type MgoLogger struct {
@@ -125,16 +205,33 @@ func dispatcherAction(ctx *cli.Context) {
 		logrus.Info("Debug mode is enabled")
 	}
 
+	cfg, err := config.Load(ctx.String("config"))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	applyFlagOverrides(ctx, cfg)
+	if err := cfg.Validate(); err != nil {
+		logrus.Fatal(err)
+	}
+
 	// initialize mongodb session
-	mongoAddr := ctx.String("mongo-addr")
-	logrus.Infof("Init mongodb on %s", mongoAddr)
-	session, err := mgo.Dial(mongoAddr)
+	logrus.Infof("Init mongodb on %s", cfg.Mongo.Addr)
+	dialInfo := &mgo.DialInfo{
+		Addrs:    strings.Split(cfg.Mongo.Addr, ","),
+		Database: cfg.Mongo.Db,
+		Username: cfg.Mongo.User,
+		Password: cfg.Mongo.Password,
+	}
+	if cfg.Mongo.ReplicaSet != "" {
+		dialInfo.ReplicaSetName = cfg.Mongo.ReplicaSet
+	}
+	session, err := mgo.DialWithInfo(dialInfo)
 	if err != nil {
 		panic(err)
 	}
 	defer session.Close()
 	logrus.Infof("Successfull")
-	dbName := ctx.String("mongo-db")
+	dbName := cfg.Mongo.Db
 	logrus.Infof("Set mongo database %s", dbName)
 
 	if ctx.GlobalBool("debug") {
@@ -142,61 +239,99 @@ func dispatcherAction(ctx *cli.Context) {
 		//		mgo.SetDebug(true)
 
 		// see what happens inside the package restful
-		// TODO (m0sth8): set output to logrus
-		restful.TraceLogger(log.New(os.Stdout, "[restful] ", log.LstdFlags|log.Lshortfile))
-
+		restful.TraceLogger(filters.StdLogger("[restful] "))
 	}
 
 	// Create container and initialize services
 	wsContainer := restful.NewContainer()
 	wsContainer.Router(restful.CurlyRouter{}) // CurlyRouter is the faster routing alternative for restful
 
+	// Stamp every request with a correlation id and a logrus entry
+	// carrying it, so route handlers can log against the same request via
+	// filters.EntryFromRequest (e.g. chatops' auth-failure logging).
+	wsContainer.Filter(filters.RequestLogFilter(logrus.StandardLogger()))
+
+	// Span covers HTTP handling end to end.
+	wsContainer.Filter(filters.TracingFilter(opentracing.GlobalTracer()))
+
+	// Per-route request counts, latencies and status codes.
+	wsContainer.Filter(filters.MetricsFilter())
+
 	// setup session
 	cookieOpts := &filters.CookieOpts{
 		Path:     "/api/",
 		HttpOnly: true,
-		//		Secure: true,
+		Secure:   cfg.Session.Secure,
 	}
-	// TODO (m0sth8): extract keys to configuration file
-	hashKey := []byte("12345678901234567890123456789012")
-	encKey := []byte("12345678901234567890123456789012")
+	hashKey := []byte(cfg.Session.HashKey)
+	encKey := []byte(cfg.Session.EncKey)
 	wsContainer.Filter(filters.SessionCookieFilter("bearded-sss", cookieOpts, hashKey, encKey))
 
 	wsContainer.Filter(filters.MongoFilter(session)) // Add mongo session copy to context on every request
 	wsContainer.DoNotRecover(true)                   // Disable recovering in restful cause we recover all panics in negroni
 
+	// Scheduler backend for both scan dispatch and the agent poll
+	// endpoint: the piece that lets several dispatcher replicas and many
+	// agents share one task queue safely.
+	backend, err := schedulerBackend(cfg, session)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	backend = scheduler.Instrument(backend)
+
+	if cfg.MetricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.Handler())
+			logrus.Infof("Serving metrics on %s", cfg.MetricsAddr)
+			if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+				logrus.Errorf("metrics listener stopped: %v", err)
+			}
+		}()
+	}
+
+	chatopsOpts := chatops.Opts{
+		SlackSigningSecret: cfg.Chatops.SlackSigningSecret,
+		MattermostToken:    cfg.Chatops.MattermostToken,
+		BaseUrl:            cfg.Chatops.BaseUrl,
+	}
+
 	// Initialize and register services in container
-	err = initServices(wsContainer, session.DB(dbName))
+	mgr, err := initServices(wsContainer, session.DB(dbName), backend, chatopsOpts)
 	if err != nil {
 		panic(err)
 	}
 
 	// Swagger should be initialized after services registration
-	if !ctx.Bool("swagger-disabled") {
-		services.Swagger(wsContainer,
-			ctx.String("swagger-api-path"),
-			ctx.String("swagger-path"),
-			ctx.String("swagger-filepath"))
+	if !cfg.Swagger.Disabled && !ctx.Bool("swagger-disabled") {
+		apiPath, path, filePath := cfg.Swagger.ApiPath, cfg.Swagger.Path, cfg.Swagger.FilePath
+		if apiPath == "" {
+			apiPath = ctx.String("swagger-api-path")
+		}
+		if path == "" {
+			path = ctx.String("swagger-path")
+		}
+		if filePath == "" {
+			filePath = ctx.String("swagger-filepath")
+		}
+		services.Swagger(wsContainer, apiPath, path, filePath)
 	}
 
 	// We user negroni as middleware framework.
 	app := negroni.New()
-	recovery := negroni.NewRecovery() // TODO (m0sth8): create recovery with ServiceError response
 
 	if ctx.GlobalBool("debug") {
-		app.Use(negroni.NewLogger())
-		// TODO (m0sth8): set output to logrus
-		// existed middleware https://github.com/meatballhat/negroni-logrus
-	} else {
-		recovery.PrintStack = false // do not print stack to response
+		nLogger := negroni.NewLogger()
+		nLogger.Logger = filters.StdLogger("[negroni] ")
+		app.Use(nLogger)
 	}
-	app.Use(recovery)
+	app.Use(panicRecovery(ctx.GlobalBool("debug"))) // TODO (m0sth8): create recovery with ServiceError response
 
 	// TODO (m0sth8): add secure middleware
 
-	if !ctx.Bool("frontend-off") {
-		logrus.Infof("Frontend served from %s directory", ctx.String("frontend"))
-		app.Use(negroni.NewStatic(http.Dir(ctx.String("frontend"))))
+	if !cfg.FrontendOff {
+		logrus.Infof("Frontend served from %s directory", cfg.Frontend)
+		app.Use(negroni.NewStatic(http.Dir(cfg.Frontend)))
 	}
 
 	app.UseHandler(wsContainer) // set wsContainer as main handler
@@ -207,9 +342,140 @@ func dispatcherAction(ctx *cli.Context) {
 		}
 	}
 
-	// Start negroini middleware with our restful container
-	bindAddr := ctx.String("bind-addr")
-	server := &http.Server{Addr: bindAddr, Handler: app}
-	logrus.Infof("Listening on %s", bindAddr)
-	logrus.Fatal(server.ListenAndServe())
+	shutdownTimeout, err := time.ParseDuration(cfg.ShutdownTimeout)
+	if err != nil {
+		logrus.Fatalf("invalid shutdown_timeout %q: %v", cfg.ShutdownTimeout, err)
+	}
+
+	// /healthz and /readyz are served straight off a plain ServeMux, in
+	// front of negroni and wsContainer, so a load balancer can probe
+	// them without going through the session/mongo filters or auth.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler(session, backend, func() (int, error) {
+		return mgr.Plugins().Count()
+	}))
+	mux.Handle("/", app)
+
+	server := &http.Server{Addr: cfg.BindAddr, Handler: mux}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.TLS.Enabled() {
+			logrus.Infof("Listening on %s (tls)", cfg.BindAddr)
+			serveErr <- server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			logrus.Infof("Listening on %s", cfg.BindAddr)
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		if err != nil && err != http.ErrServerClosed {
+			logrus.Fatal(err)
+		}
+	case sig := <-sigCh:
+		logrus.Infof("Received %s, shutting down gracefully (timeout %s)", sig, shutdownTimeout)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		// Also drains the internal agent's long-poll requests when
+		// --with-agent is set, since it talks to this same server.
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logrus.Errorf("graceful shutdown did not complete cleanly: %v", err)
+		}
+
+		if err := backend.Stop(); err != nil {
+			logrus.Errorf("scheduler backend did not stop cleanly: %v", err)
+		}
+
+		logrus.Info("Shutdown complete")
+	}
+}
+
+// panicRecovery replaces negroni's bundled Recovery middleware so a
+// recovered panic logs through logrus, tagged with the request's
+// correlation id, instead of printing through negroni's own logger. By
+// the time a handler deep inside wsContainer panics, filters.RequestLogFilter
+// has already set the X-Request-Id response header, so it's still there
+// to read from rw when recover() unwinds back up to us.
+func panicRecovery(printStack bool) negroni.HandlerFunc {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			entry := logrus.WithField("request_id", rw.Header().Get(filters.RequestIdHeader))
+			if printStack {
+				entry.Errorf("PANIC: %v\n%s", rec, debug.Stack())
+			} else {
+				entry.Errorf("PANIC: %v", rec)
+			}
+			rw.WriteHeader(http.StatusInternalServerError)
+		}()
+		next(rw, r)
+	})
+}
+
+// applyFlagOverrides lets the handful of pre-existing CLI flags win over
+// the config file, so operators don't have to rewrite scripts that
+// already pass --bind-addr, --mongo-addr, etc.
+func applyFlagOverrides(ctx *cli.Context, cfg *config.Config) {
+	if ctx.IsSet("bind-addr") {
+		cfg.BindAddr = ctx.String("bind-addr")
+	}
+	if ctx.IsSet("mongo-addr") {
+		cfg.Mongo.Addr = ctx.String("mongo-addr")
+	}
+	if ctx.IsSet("mongo-db") {
+		cfg.Mongo.Db = ctx.String("mongo-db")
+	}
+	if ctx.IsSet("frontend") {
+		cfg.Frontend = ctx.String("frontend")
+	}
+	if ctx.Bool("frontend-off") {
+		cfg.FrontendOff = true
+	}
+	if ctx.IsSet("scheduler-backend") {
+		cfg.Scheduler.Backend = ctx.String("scheduler-backend")
+	}
+	if ctx.IsSet("slack-signing-secret") {
+		cfg.Chatops.SlackSigningSecret = ctx.String("slack-signing-secret")
+	}
+	if ctx.IsSet("chatops-base-url") {
+		cfg.Chatops.BaseUrl = ctx.String("chatops-base-url")
+	}
+	if ctx.IsSet("shutdown-timeout") {
+		cfg.ShutdownTimeout = ctx.String("shutdown-timeout")
+	}
+	if ctx.IsSet("metrics-addr") {
+		cfg.MetricsAddr = ctx.String("metrics-addr")
+	}
+}
+
+// schedulerBackend builds the scheduler.Backend selected by
+// cfg.Scheduler.Backend. session is reused (copied per operation) for the
+// mongo backend so it shares the dispatcher's connection pool.
+func schedulerBackend(cfg *config.Config, session *mgo.Session) (scheduler.Backend, error) {
+	switch cfg.Scheduler.Backend {
+	case "mongo":
+		return scheduler.NewMongoBackend(session, cfg.Mongo.Db), nil
+	case "redis":
+		pool := &redis.Pool{
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", cfg.Scheduler.RedisAddr)
+			},
+		}
+		return scheduler.NewRedisBackend(pool), nil
+	case "memory", "":
+		return scheduler.NewMemoryBackend(), nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler backend %q", cfg.Scheduler.Backend)
+	}
 }
@@ -0,0 +1,72 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"net/http"
+
+	mgo "gopkg.in/mgo.v2"
+
+	"github.com/bearded-web/bearded/pkg/scheduler"
+)
+
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// healthzHandler just answers that the process is alive: no dependency
+// checks, so it stays fast and cheap enough for a liveness probe.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, &healthStatus{Status: "ok"})
+}
+
+// readyzHandler reports ready only once mongo answers a ping, the
+// scheduler backend can be listed and at least one plugin is loaded, so
+// a load balancer doesn't send traffic to a dispatcher that can't yet
+// serve requests.
+func readyzHandler(session *mgo.Session, backend scheduler.Backend, pluginCount func() (int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]string{}
+		ready := true
+
+		s := session.Copy()
+		defer s.Close()
+		if err := s.Ping(); err != nil {
+			ready = false
+			checks["mongo"] = err.Error()
+		} else {
+			checks["mongo"] = "ok"
+		}
+
+		if _, err := backend.List(); err != nil {
+			ready = false
+			checks["scheduler"] = err.Error()
+		} else {
+			checks["scheduler"] = "ok"
+		}
+
+		if n, err := pluginCount(); err != nil {
+			ready = false
+			checks["plugins"] = err.Error()
+		} else if n == 0 {
+			ready = false
+			checks["plugins"] = "no plugins loaded"
+		} else {
+			checks["plugins"] = "ok"
+		}
+
+		status := &healthStatus{Status: "ready", Checks: checks}
+		code := http.StatusOK
+		if !ready {
+			status.Status = "not ready"
+			code = http.StatusServiceUnavailable
+		}
+		writeHealth(w, code, status)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, code int, status *healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,213 @@
+// Package config loads Dispatcher configuration from a YAML or JSON file,
+// applies environment variable overrides and validates the result before
+// the service is allowed to start.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// placeholderKey is the insecure key that used to be hard-coded in
+// dispatcherAction. Configs that still carry it are refused on boot.
+const placeholderKey = "12345678901234567890123456789012"
+
+type MongoConfig struct {
+	Addr       string `yaml:"addr" json:"addr"`
+	Db         string `yaml:"db" json:"db"`
+	ReplicaSet string `yaml:"replica_set" json:"replica_set"`
+	User       string `yaml:"user" json:"user"`
+	Password   string `yaml:"password" json:"password"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file"`
+}
+
+// Enabled reports whether both TLS files were configured.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+type SessionConfig struct {
+	HashKey string `yaml:"hash_key" json:"hash_key"`
+	EncKey  string `yaml:"enc_key" json:"enc_key"`
+	Secure  bool   `yaml:"secure" json:"secure"`
+}
+
+type SchedulerConfig struct {
+	Backend   string `yaml:"backend" json:"backend"` // memory, mongo or redis
+	RedisAddr string `yaml:"redis_addr" json:"redis_addr"`
+}
+
+type ChatopsConfig struct {
+	SlackSigningSecret string `yaml:"slack_signing_secret" json:"slack_signing_secret"`
+	MattermostToken    string `yaml:"mattermost_token" json:"mattermost_token"`
+	BaseUrl            string `yaml:"base_url" json:"base_url"`
+}
+
+type SwaggerConfig struct {
+	Disabled bool   `yaml:"disabled" json:"disabled"`
+	ApiPath  string `yaml:"api_path" json:"api_path"`
+	Path     string `yaml:"path" json:"path"`
+	FilePath string `yaml:"filepath" json:"filepath"`
+}
+
+// Config is the root Dispatcher configuration, loaded from --config and
+// overridden by environment variables and a handful of CLI flags.
+type Config struct {
+	BindAddr    string          `yaml:"bind_addr" json:"bind_addr"`
+	Frontend    string          `yaml:"frontend" json:"frontend"`
+	FrontendOff bool            `yaml:"frontend_off" json:"frontend_off"`
+	Mongo       MongoConfig     `yaml:"mongo" json:"mongo"`
+	TLS         TLSConfig       `yaml:"tls" json:"tls"`
+	Session     SessionConfig   `yaml:"session" json:"session"`
+	Swagger     SwaggerConfig   `yaml:"swagger" json:"swagger"`
+	Scheduler   SchedulerConfig `yaml:"scheduler" json:"scheduler"`
+	Chatops     ChatopsConfig   `yaml:"chatops" json:"chatops"`
+
+	// ShutdownTimeout bounds how long dispatcherAction waits for
+	// in-flight requests to finish during a graceful shutdown. Stored
+	// as a string so it parses the same way whether it comes from the
+	// config file or --shutdown-timeout.
+	ShutdownTimeout string `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+
+	// MetricsAddr, if set, serves Prometheus metrics on its own
+	// listener. Left empty, /metrics is not served at all.
+	MetricsAddr string `yaml:"metrics_addr" json:"metrics_addr"`
+}
+
+// Default returns the configuration used when no --config file is given.
+// It intentionally keeps the placeholder session keys so that Validate
+// fails loudly instead of booting with an insecure default.
+func Default() *Config {
+	return &Config{
+		BindAddr: "127.0.0.1:3003",
+		Frontend: "../frontend/dist/",
+		Mongo: MongoConfig{
+			Addr: "127.0.0.1",
+			Db:   "bearded",
+		},
+		Session: SessionConfig{
+			HashKey: placeholderKey,
+			EncKey:  placeholderKey,
+		},
+		Scheduler: SchedulerConfig{
+			Backend: "memory",
+		},
+		ShutdownTimeout: "15s",
+	}
+}
+
+// Load reads the config file at path (if any), applies BEARDED_* env
+// overrides and validates the result. path may be empty, in which case
+// only defaults and env overrides apply.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to read %s: %v", path, err)
+		}
+		unmarshal := yaml.Unmarshal
+		if strings.HasSuffix(path, ".json") {
+			unmarshal = json.Unmarshal
+		}
+		if err := unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config: failed to parse %s: %v", path, err)
+		}
+	}
+
+	applyEnvOverrides(cfg)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnvOverrides lets operators override secrets and connection
+// details without touching the config file, e.g. in containers.
+func applyEnvOverrides(cfg *Config) {
+	if v := os.Getenv("BEARDED_SESSION_HASH_KEY"); v != "" {
+		cfg.Session.HashKey = v
+	}
+	if v := os.Getenv("BEARDED_SESSION_ENC_KEY"); v != "" {
+		cfg.Session.EncKey = v
+	}
+	if v := os.Getenv("BEARDED_MONGO_ADDR"); v != "" {
+		cfg.Mongo.Addr = v
+	}
+	if v := os.Getenv("BEARDED_MONGO_DB"); v != "" {
+		cfg.Mongo.Db = v
+	}
+	if v := os.Getenv("BEARDED_MONGO_REPLICA_SET"); v != "" {
+		cfg.Mongo.ReplicaSet = v
+	}
+	if v := os.Getenv("BEARDED_MONGO_USER"); v != "" {
+		cfg.Mongo.User = v
+	}
+	if v := os.Getenv("BEARDED_MONGO_PASSWORD"); v != "" {
+		cfg.Mongo.Password = v
+	}
+	if v := os.Getenv("BEARDED_TLS_CERT_FILE"); v != "" {
+		cfg.TLS.CertFile = v
+	}
+	if v := os.Getenv("BEARDED_TLS_KEY_FILE"); v != "" {
+		cfg.TLS.KeyFile = v
+	}
+	if v := os.Getenv("BEARDED_SHUTDOWN_TIMEOUT"); v != "" {
+		cfg.ShutdownTimeout = v
+	}
+	if v := os.Getenv("BEARDED_METRICS_ADDR"); v != "" {
+		cfg.MetricsAddr = v
+	}
+}
+
+// Validate refuses to boot with placeholder session keys or an
+// incomplete TLS pair, which are the two classes of mistake that are
+// easy to leave in place by accident.
+func (c *Config) Validate() error {
+	if c.Session.HashKey == "" || c.Session.HashKey == placeholderKey {
+		return fmt.Errorf("config: session.hash_key must be set to a non-default value")
+	}
+	if c.Session.EncKey == "" || c.Session.EncKey == placeholderKey {
+		return fmt.Errorf("config: session.enc_key must be set to a non-default value")
+	}
+	if len(c.Session.HashKey) != 32 {
+		return fmt.Errorf("config: session.hash_key must be 32 bytes, got %d", len(c.Session.HashKey))
+	}
+	if len(c.Session.EncKey) != 32 {
+		return fmt.Errorf("config: session.enc_key must be 32 bytes, got %d", len(c.Session.EncKey))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("config: tls.cert_file and tls.key_file must be set together")
+	}
+	if c.Mongo.Addr == "" {
+		return fmt.Errorf("config: mongo.addr is required")
+	}
+	if c.Mongo.Db == "" {
+		return fmt.Errorf("config: mongo.db is required")
+	}
+	switch c.Scheduler.Backend {
+	case "memory", "mongo":
+	case "redis":
+		if c.Scheduler.RedisAddr == "" {
+			return fmt.Errorf("config: scheduler.redis_addr is required when scheduler.backend is redis")
+		}
+	default:
+		return fmt.Errorf("config: scheduler.backend must be one of memory, mongo, redis, got %q", c.Scheduler.Backend)
+	}
+	if _, err := time.ParseDuration(c.ShutdownTimeout); err != nil {
+		return fmt.Errorf("config: shutdown_timeout %q is not a valid duration: %v", c.ShutdownTimeout, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import "testing"
+
+// validConfig returns a Config that passes Validate, so each test case
+// only needs to override the one field it's exercising.
+func validConfig() *Config {
+	return &Config{
+		Mongo: MongoConfig{
+			Addr: "127.0.0.1",
+			Db:   "bearded",
+		},
+		Session: SessionConfig{
+			HashKey: "01234567890123456789012345678901",
+			EncKey:  "01234567890123456789012345678901",
+		},
+		Scheduler: SchedulerConfig{
+			Backend: "memory",
+		},
+		ShutdownTimeout: "15s",
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr bool
+	}{
+		{name: "valid config", mutate: func(c *Config) {}, wantErr: false},
+		{
+			name:    "placeholder hash key is rejected",
+			mutate:  func(c *Config) { c.Session.HashKey = placeholderKey },
+			wantErr: true,
+		},
+		{
+			name:    "empty hash key is rejected",
+			mutate:  func(c *Config) { c.Session.HashKey = "" },
+			wantErr: true,
+		},
+		{
+			name:    "placeholder enc key is rejected",
+			mutate:  func(c *Config) { c.Session.EncKey = placeholderKey },
+			wantErr: true,
+		},
+		{
+			name:    "short hash key is rejected",
+			mutate:  func(c *Config) { c.Session.HashKey = "tooshort" },
+			wantErr: true,
+		},
+		{
+			name:    "short enc key is rejected",
+			mutate:  func(c *Config) { c.Session.EncKey = "tooshort" },
+			wantErr: true,
+		},
+		{
+			name:    "tls cert without key is rejected",
+			mutate:  func(c *Config) { c.TLS.CertFile = "cert.pem" },
+			wantErr: true,
+		},
+		{
+			name:    "tls key without cert is rejected",
+			mutate:  func(c *Config) { c.TLS.KeyFile = "key.pem" },
+			wantErr: true,
+		},
+		{
+			name: "tls cert and key together is accepted",
+			mutate: func(c *Config) {
+				c.TLS.CertFile = "cert.pem"
+				c.TLS.KeyFile = "key.pem"
+			},
+			wantErr: false,
+		},
+		{
+			name:    "empty mongo addr is rejected",
+			mutate:  func(c *Config) { c.Mongo.Addr = "" },
+			wantErr: true,
+		},
+		{
+			name:    "empty mongo db is rejected",
+			mutate:  func(c *Config) { c.Mongo.Db = "" },
+			wantErr: true,
+		},
+		{
+			name:    "mongo scheduler backend needs no redis addr",
+			mutate:  func(c *Config) { c.Scheduler.Backend = "mongo" },
+			wantErr: false,
+		},
+		{
+			name:    "redis scheduler backend without redis addr is rejected",
+			mutate:  func(c *Config) { c.Scheduler.Backend = "redis" },
+			wantErr: true,
+		},
+		{
+			name: "redis scheduler backend with redis addr is accepted",
+			mutate: func(c *Config) {
+				c.Scheduler.Backend = "redis"
+				c.Scheduler.RedisAddr = "127.0.0.1:6379"
+			},
+			wantErr: false,
+		},
+		{
+			name:    "unknown scheduler backend is rejected",
+			mutate:  func(c *Config) { c.Scheduler.Backend = "bogus" },
+			wantErr: true,
+		},
+		{
+			name:    "invalid shutdown timeout is rejected",
+			mutate:  func(c *Config) { c.ShutdownTimeout = "not-a-duration" },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.mutate(c)
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
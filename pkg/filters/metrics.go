@@ -0,0 +1,28 @@
+package filters
+
+import (
+	"strconv"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/bearded-web/bearded/pkg/metrics"
+)
+
+// MetricsFilter records per-route request counts, latencies and status
+// codes into the bearded_http_* collectors in pkg/metrics.
+func MetricsFilter() restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		start := time.Now()
+		chain.ProcessFilter(req, resp)
+
+		route := req.SelectedRoutePath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := req.Request.Method
+
+		metrics.HttpRequestDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+		metrics.HttpRequestsTotal.WithLabelValues(route, method, strconv.Itoa(resp.StatusCode())).Inc()
+	}
+}
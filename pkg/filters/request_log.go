@@ -0,0 +1,72 @@
+package filters
+
+import (
+	"log"
+
+	"github.com/Sirupsen/logrus"
+	restful "github.com/emicklei/go-restful"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	// RequestIdAttribute is the restful.Request attribute holding the
+	// correlation id for the current request.
+	RequestIdAttribute = "request-id"
+	// LogEntryAttribute is the restful.Request attribute holding the
+	// *logrus.Entry scoped to the current request.
+	LogEntryAttribute = "log-entry"
+
+	RequestIdHeader     = "X-Request-Id"
+	CorrelationIdHeader = "X-Correlation-Id"
+)
+
+// RequestLogFilter stamps every request with a correlation id, reusing an
+// inbound X-Request-Id/X-Correlation-Id header when the caller already has
+// one, and stashes a *logrus.Entry carrying that id in the request
+// attributes so handlers and other filters can log with it. The id is
+// echoed back on the response so callers can correlate on their side too.
+func RequestLogFilter(logger *logrus.Logger) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		reqId := req.HeaderParameter(RequestIdHeader)
+		if reqId == "" {
+			reqId = req.HeaderParameter(CorrelationIdHeader)
+		}
+		if reqId == "" {
+			reqId = uuid.NewV4().String()
+		}
+
+		entry := logger.WithField("request_id", reqId)
+		req.SetAttribute(RequestIdAttribute, reqId)
+		req.SetAttribute(LogEntryAttribute, entry)
+		resp.Header().Set(RequestIdHeader, reqId)
+
+		entry.WithFields(logrus.Fields{
+			"method": req.Request.Method,
+			"path":   req.Request.URL.Path,
+		}).Debug("request started")
+
+		chain.ProcessFilter(req, resp)
+
+		entry.WithField("status", resp.StatusCode()).Debug("request completed")
+	}
+}
+
+// EntryFromRequest returns the per-request logrus entry stashed by
+// RequestLogFilter, falling back to a bare entry on fallback when
+// RequestLogFilter did not run for req.
+func EntryFromRequest(req *restful.Request, fallback *logrus.Logger) *logrus.Entry {
+	if v := req.Attribute(LogEntryAttribute); v != nil {
+		if entry, ok := v.(*logrus.Entry); ok {
+			return entry
+		}
+	}
+	return logrus.NewEntry(fallback)
+}
+
+// StdLogger adapts the standard library logger interface expected by
+// go-restful's TraceLogger and negroni's Logger middleware to forward
+// through logrus, so debug output from both libraries lands in the same
+// structured stream as everything else.
+func StdLogger(prefix string) *log.Logger {
+	return log.New(logrus.StandardLogger().Writer(), prefix, 0)
+}
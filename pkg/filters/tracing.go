@@ -0,0 +1,47 @@
+package filters
+
+import (
+	restful "github.com/emicklei/go-restful"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// SpanAttribute is the restful.Request attribute holding the span
+// TracingFilter started for the current request.
+const SpanAttribute = "span"
+
+// TracingFilter extracts an OpenTracing span context from incoming
+// headers, if the caller propagated one, and starts a server span
+// covering the whole request. The span is stashed in the request
+// attributes so handlers and managers can start child spans for the
+// mongo calls they make while handling it. The scheduler's spans are root
+// spans and do not use it.
+func TracingFilter(tracer opentracing.Tracer) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		opName := req.Request.Method + " " + req.Request.URL.Path
+
+		spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Request.Header))
+		span := tracer.StartSpan(opName, ext.RPCServerOption(spanCtx))
+		defer span.Finish()
+
+		ext.HTTPMethod.Set(span, req.Request.Method)
+		ext.HTTPUrl.Set(span, req.Request.URL.String())
+
+		req.SetAttribute(SpanAttribute, span)
+
+		chain.ProcessFilter(req, resp)
+
+		ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode()))
+	}
+}
+
+// SpanFromRequest returns the span TracingFilter stashed for req, or a
+// fresh no-op span when TracingFilter did not run for req.
+func SpanFromRequest(req *restful.Request) opentracing.Span {
+	if v := req.Attribute(SpanAttribute); v != nil {
+		if span, ok := v.(opentracing.Span); ok {
+			return span
+		}
+	}
+	return opentracing.NoopTracer{}.StartSpan("noop")
+}
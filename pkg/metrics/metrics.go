@@ -0,0 +1,95 @@
+// Package metrics holds the Prometheus collectors shared by the HTTP
+// filters, the mongo-backed managers and the scheduler, so a single /metrics
+// endpoint gives operators request, storage and queue visibility for a
+// dispatcher that previously exposed none of it.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	HttpRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "bearded",
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Total HTTP requests, labeled by route, method and status.",
+		},
+		[]string{"route", "method", "status"},
+	)
+
+	HttpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "bearded",
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency, labeled by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method"},
+	)
+
+	MongoQueriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "bearded",
+			Subsystem: "mongo",
+			Name:      "queries_total",
+			Help:      "Total mongo queries, labeled by collection and operation.",
+		},
+		[]string{"collection", "op"},
+	)
+
+	MongoQueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "bearded",
+			Subsystem: "mongo",
+			Name:      "query_duration_seconds",
+			Help:      "Mongo query latency, labeled by collection and operation.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"collection", "op"},
+	)
+
+	SchedulerTasksQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bearded",
+		Subsystem: "scheduler",
+		Name:      "tasks_queued",
+		Help:      "Tasks currently waiting to be claimed by an agent.",
+	})
+
+	SchedulerTasksRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bearded",
+		Subsystem: "scheduler",
+		Name:      "tasks_running",
+		Help:      "Tasks currently claimed by an agent.",
+	})
+
+	SchedulerTasksFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "bearded",
+		Subsystem: "scheduler",
+		Name:      "tasks_failed_total",
+		Help:      "Total tasks an agent reported as failed.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HttpRequestsTotal,
+		HttpRequestDuration,
+		MongoQueriesTotal,
+		MongoQueryDuration,
+		SchedulerTasksQueued,
+		SchedulerTasksRunning,
+		SchedulerTasksFailed,
+	)
+}
+
+// TimeMongoQuery returns a func that, when deferred, records the query's
+// duration and bumps MongoQueriesTotal for collection/op. Managers wrap
+// their mgo calls with it: defer metrics.TimeMongoQuery("scans", "find")().
+func TimeMongoQuery(collection, op string) func() {
+	timer := prometheus.NewTimer(MongoQueryDuration.WithLabelValues(collection, op))
+	return func() {
+		timer.ObserveDuration()
+		MongoQueriesTotal.WithLabelValues(collection, op).Inc()
+	}
+}
@@ -0,0 +1,41 @@
+package scheduler
+
+import "time"
+
+// Task is a unit of work handed out to an agent, e.g. a scan to run.
+type Task struct {
+	Id        string    `bson:"_id" json:"id"`
+	ScanId    string    `bson:"scanId" json:"scanId"`
+	Payload   []byte    `bson:"payload" json:"payload"`
+	Attempts  int       `bson:"attempts" json:"attempts"`
+	VisibleAt time.Time `bson:"visibleAt" json:"visibleAt"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// Backend is the queue a Scheduler dispatches tasks through. Unlike the
+// in-process MemoryScheduler, a Backend implementation is safe to share
+// across multiple dispatcher replicas: Dequeue hides a task from other
+// callers until its visibility timeout expires or it is Ack'd/Nack'd, so
+// two dispatchers polling the same backend never hand the same task to
+// two agents at once.
+type Backend interface {
+	// Enqueue adds a new task, making it immediately visible to Dequeue.
+	Enqueue(task *Task) error
+	// Dequeue claims up to one task, hiding it from other callers for
+	// visibilityTimeout. Returns nil, nil if no task is currently visible.
+	Dequeue(visibilityTimeout time.Duration) (*Task, error)
+	// Ack marks a task as completed and removes it from the backend.
+	Ack(taskId string) error
+	// Nack makes a claimed task immediately visible again, e.g. after an
+	// agent reports it failed to run the task.
+	Nack(taskId string) error
+	// Reschedule makes a claimed task visible again at visibleAt, for
+	// retrying later instead of immediately.
+	Reschedule(taskId string, visibleAt time.Time) error
+	// List returns every task currently known to the backend, for status
+	// and debugging endpoints.
+	List() ([]*Task, error)
+	// Stop releases any resources the backend is holding, e.g. a redis
+	// pool it owns. It is called once, during graceful shutdown.
+	Stop() error
+}
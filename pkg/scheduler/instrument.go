@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"github.com/bearded-web/bearded/pkg/metrics"
+)
+
+// CompletionListener is called with a task's ScanId once the task that
+// carried it has been Ack'd, i.e. the scan it represents has finished.
+type CompletionListener func(scanId string)
+
+// instrumentedBackend wraps a Backend so its Enqueue/Dequeue/Ack/Nack/
+// Reschedule calls keep the bearded_scheduler_tasks_* Prometheus collectors
+// accurate, whichever Backend implementation is actually in use, and so
+// each call gets its own OpenTracing span covering scan dispatch. Backend
+// operations aren't tied to an inbound HTTP request (Dequeue is usually
+// called from an agent's long poll, long after the request that enqueued
+// the task finished), so these spans are root spans rather than children
+// of a request span. It also tracks which scan each claimed task belongs
+// to, so callers such as services/chatops can be notified when that
+// scan's task completes without every Backend implementation having to
+// know about listeners.
+type instrumentedBackend struct {
+	Backend
+
+	mu        sync.Mutex
+	pending   map[string]string // taskId -> ScanId, populated on Dequeue
+	listeners []CompletionListener
+}
+
+// Instrument wraps backend with Prometheus instrumentation, tracing and
+// completion notifications.
+func Instrument(backend Backend) Backend {
+	return &instrumentedBackend{Backend: backend, pending: make(map[string]string)}
+}
+
+// AddCompletionListener registers fn to be called with a task's ScanId
+// whenever that task is Ack'd.
+func (i *instrumentedBackend) AddCompletionListener(fn CompletionListener) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.listeners = append(i.listeners, fn)
+}
+
+func (i *instrumentedBackend) Enqueue(task *Task) error {
+	span := opentracing.GlobalTracer().StartSpan("scheduler.Enqueue")
+	defer span.Finish()
+
+	if err := i.Backend.Enqueue(task); err != nil {
+		ext.Error.Set(span, true)
+		return err
+	}
+	span.SetTag("taskId", task.Id)
+	metrics.SchedulerTasksQueued.Inc()
+	return nil
+}
+
+func (i *instrumentedBackend) Dequeue(visibilityTimeout time.Duration) (*Task, error) {
+	span := opentracing.GlobalTracer().StartSpan("scheduler.Dequeue")
+	defer span.Finish()
+
+	task, err := i.Backend.Dequeue(visibilityTimeout)
+	if err != nil {
+		ext.Error.Set(span, true)
+		return task, err
+	}
+	if task == nil {
+		return task, nil
+	}
+	span.SetTag("taskId", task.Id)
+	span.SetTag("scanId", task.ScanId)
+	metrics.SchedulerTasksQueued.Dec()
+	metrics.SchedulerTasksRunning.Inc()
+
+	i.mu.Lock()
+	i.pending[task.Id] = task.ScanId
+	i.mu.Unlock()
+
+	return task, nil
+}
+
+func (i *instrumentedBackend) Ack(taskId string) error {
+	span := opentracing.GlobalTracer().StartSpan("scheduler.Ack")
+	span.SetTag("taskId", taskId)
+	defer span.Finish()
+
+	if err := i.Backend.Ack(taskId); err != nil {
+		ext.Error.Set(span, true)
+		return err
+	}
+	metrics.SchedulerTasksRunning.Dec()
+
+	i.mu.Lock()
+	scanId, ok := i.pending[taskId]
+	delete(i.pending, taskId)
+	listeners := i.listeners
+	i.mu.Unlock()
+
+	if ok && scanId != "" {
+		for _, fn := range listeners {
+			fn(scanId)
+		}
+	}
+	return nil
+}
+
+func (i *instrumentedBackend) Nack(taskId string) error {
+	span := opentracing.GlobalTracer().StartSpan("scheduler.Nack")
+	span.SetTag("taskId", taskId)
+	defer span.Finish()
+
+	if err := i.Backend.Nack(taskId); err != nil {
+		ext.Error.Set(span, true)
+		return err
+	}
+	metrics.SchedulerTasksRunning.Dec()
+	metrics.SchedulerTasksQueued.Inc()
+	metrics.SchedulerTasksFailed.Inc()
+	return nil
+}
+
+func (i *instrumentedBackend) Reschedule(taskId string, visibleAt time.Time) error {
+	span := opentracing.GlobalTracer().StartSpan("scheduler.Reschedule")
+	span.SetTag("taskId", taskId)
+	defer span.Finish()
+
+	if err := i.Backend.Reschedule(taskId, visibleAt); err != nil {
+		ext.Error.Set(span, true)
+		return err
+	}
+	metrics.SchedulerTasksRunning.Dec()
+	metrics.SchedulerTasksQueued.Inc()
+	return nil
+}
@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// memoryBackend is the reference Backend implementation: an in-process
+// queue guarded by a mutex. It does not coordinate across dispatcher
+// replicas, so it should only be used for single-instance deployments.
+type memoryBackend struct {
+	mu    sync.Mutex
+	tasks map[string]*Task
+}
+
+// NewMemoryBackend returns a Backend that keeps tasks in memory.
+func NewMemoryBackend() Backend {
+	return &memoryBackend{tasks: make(map[string]*Task)}
+}
+
+func (m *memoryBackend) Enqueue(task *Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if task.Id == "" {
+		task.Id = uuid.NewV4().String()
+	}
+	task.CreatedAt = time.Now()
+	task.VisibleAt = task.CreatedAt
+	m.tasks[task.Id] = task
+	return nil
+}
+
+func (m *memoryBackend) Dequeue(visibilityTimeout time.Duration) (*Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var oldest *Task
+	for _, task := range m.tasks {
+		if task.VisibleAt.After(now) {
+			continue
+		}
+		if oldest == nil || task.CreatedAt.Before(oldest.CreatedAt) {
+			oldest = task
+		}
+	}
+	if oldest == nil {
+		return nil, nil
+	}
+	oldest.Attempts++
+	oldest.VisibleAt = now.Add(visibilityTimeout)
+
+	claimed := *oldest
+	return &claimed, nil
+}
+
+func (m *memoryBackend) Ack(taskId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tasks, taskId)
+	return nil
+}
+
+func (m *memoryBackend) Nack(taskId string) error {
+	return m.Reschedule(taskId, time.Now())
+}
+
+func (m *memoryBackend) Reschedule(taskId string, visibleAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.tasks[taskId]
+	if !ok {
+		return fmt.Errorf("scheduler: task %s not found", taskId)
+	}
+	task.VisibleAt = visibleAt
+	return nil
+}
+
+func (m *memoryBackend) List() ([]*Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := make([]*Task, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		copied := *task
+		tasks = append(tasks, &copied)
+	}
+	return tasks, nil
+}
+
+// Stop is a no-op: memoryBackend owns nothing beyond its own map.
+func (m *memoryBackend) Stop() error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/bearded-web/bearded/pkg/metrics"
+)
+
+const tasksCollection = "scheduler.tasks"
+
+// MongoBackend is a Backend backed by a MongoDB collection. It claims a
+// task with findAndModify, which Mongo guarantees is atomic, so many
+// dispatcher replicas can share one queue without double-claiming a task.
+type MongoBackend struct {
+	session *mgo.Session
+	dbName  string
+}
+
+// NewMongoBackend returns a Backend storing tasks in dbName. The caller
+// keeps ownership of session; NewMongoBackend copies it per operation.
+func NewMongoBackend(session *mgo.Session, dbName string) *MongoBackend {
+	return &MongoBackend{session: session, dbName: dbName}
+}
+
+func (m *MongoBackend) collection() (*mgo.Session, *mgo.Collection) {
+	s := m.session.Copy()
+	return s, s.DB(m.dbName).C(tasksCollection)
+}
+
+func (m *MongoBackend) Enqueue(task *Task) error {
+	defer metrics.TimeMongoQuery(tasksCollection, "insert")()
+	s, c := m.collection()
+	defer s.Close()
+
+	if task.Id == "" {
+		task.Id = bson.NewObjectId().Hex()
+	}
+	task.CreatedAt = time.Now()
+	task.VisibleAt = task.CreatedAt
+	return c.Insert(task)
+}
+
+func (m *MongoBackend) Dequeue(visibilityTimeout time.Duration) (*Task, error) {
+	defer metrics.TimeMongoQuery(tasksCollection, "findAndModify")()
+	s, c := m.collection()
+	defer s.Close()
+
+	now := time.Now()
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{"visibleAt": now.Add(visibilityTimeout)},
+			"$inc": bson.M{"attempts": 1},
+		},
+		ReturnNew: true,
+	}
+	var task Task
+	_, err := c.Find(bson.M{"visibleAt": bson.M{"$lte": now}}).Sort("createdAt").Apply(change, &task)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (m *MongoBackend) Ack(taskId string) error {
+	defer metrics.TimeMongoQuery(tasksCollection, "remove")()
+	s, c := m.collection()
+	defer s.Close()
+	return c.RemoveId(taskId)
+}
+
+func (m *MongoBackend) Nack(taskId string) error {
+	return m.Reschedule(taskId, time.Now())
+}
+
+func (m *MongoBackend) Reschedule(taskId string, visibleAt time.Time) error {
+	defer metrics.TimeMongoQuery(tasksCollection, "update")()
+	s, c := m.collection()
+	defer s.Close()
+	return c.UpdateId(taskId, bson.M{"$set": bson.M{"visibleAt": visibleAt}})
+}
+
+func (m *MongoBackend) List() ([]*Task, error) {
+	defer metrics.TimeMongoQuery(tasksCollection, "find")()
+	s, c := m.collection()
+	defer s.Close()
+
+	var tasks []*Task
+	if err := c.Find(nil).Sort("createdAt").All(&tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Stop is a no-op: MongoBackend copies the session it's given per
+// operation but never owns it, so there's nothing here to release.
+func (m *MongoBackend) Stop() error {
+	return nil
+}
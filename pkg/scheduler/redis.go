@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/satori/go.uuid"
+)
+
+// redisQueueKey is a sorted set of task ids scored by visibility time.
+// redisTasksKey is a hash of task id -> JSON-encoded Task. Keeping the
+// task id as the sorted-set member (instead of the encoded task itself)
+// means Ack/Nack/Reschedule can address a task directly instead of
+// scanning the whole queue for a byte-for-byte match, and a concurrent
+// re-encode of the task (e.g. a Nack racing an Ack) can never make a
+// ZREM silently miss.
+const (
+	redisQueueKey = "bearded:scheduler:tasks"
+	redisTasksKey = "bearded:scheduler:tasks:data"
+)
+
+// redisDequeueScript claims the earliest visible task id by moving its
+// score into the future in the same round trip it reads it, so the claim
+// stays atomic across dispatcher replicas sharing the same Redis
+// instance: a concurrent Dequeue's ZRANGEBYSCORE won't see the id again
+// until the new score elapses.
+var redisDequeueScript = redis.NewScript(2, `
+	local queueKey = KEYS[1]
+	local tasksKey = KEYS[2]
+	local now = tonumber(ARGV[1])
+	local visibleAt = tonumber(ARGV[2])
+	local ids = redis.call('ZRANGEBYSCORE', queueKey, '-inf', now, 'LIMIT', 0, 1)
+	if #ids == 0 then
+		return false
+	end
+	local id = ids[1]
+	redis.call('ZADD', queueKey, visibleAt, id)
+	local data = redis.call('HGET', tasksKey, id)
+	return {id, data}
+`)
+
+// RedisBackend is an optional Backend backed by a Redis sorted set scored
+// by visibility time. It is a lighter-weight alternative to MongoBackend
+// for deployments that already run Redis for other queues.
+type RedisBackend struct {
+	pool *redis.Pool
+}
+
+// NewRedisBackend returns a Backend storing tasks in pool.
+func NewRedisBackend(pool *redis.Pool) *RedisBackend {
+	return &RedisBackend{pool: pool}
+}
+
+func (r *RedisBackend) Enqueue(task *Task) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	if task.Id == "" {
+		task.Id = uuid.NewV4().String()
+	}
+	task.CreatedAt = time.Now()
+	task.VisibleAt = task.CreatedAt
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	conn.Send("MULTI")
+	conn.Send("HSET", redisTasksKey, task.Id, data)
+	conn.Send("ZADD", redisQueueKey, task.VisibleAt.UnixNano(), task.Id)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (r *RedisBackend) Dequeue(visibilityTimeout time.Duration) (*Task, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	now := time.Now()
+	reply, err := redis.Values(redisDequeueScript.Do(conn, redisQueueKey, redisTasksKey, now.UnixNano(), now.Add(visibilityTimeout).UnixNano()))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var id string
+	var data []byte
+	if _, err := redis.Scan(reply, &id, &data); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		// The id was still in the queue but its data hash entry is gone,
+		// so there is no task to hand out.
+		return nil, nil
+	}
+
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, err
+	}
+	task.Attempts++
+	task.VisibleAt = now.Add(visibilityTimeout)
+	if err := r.put(conn, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// put persists task's current encoding in the data hash, keyed by its
+// stable id, without touching its position in the queue sorted set.
+func (r *RedisBackend) put(conn redis.Conn, task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("HSET", redisTasksKey, task.Id, data)
+	return err
+}
+
+func (r *RedisBackend) Ack(taskId string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("ZREM", redisQueueKey, taskId)
+	conn.Send("HDEL", redisTasksKey, taskId)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (r *RedisBackend) Nack(taskId string) error {
+	return r.Reschedule(taskId, time.Now())
+}
+
+func (r *RedisBackend) Reschedule(taskId string, visibleAt time.Time) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("HGET", redisTasksKey, taskId))
+	if err != nil {
+		return err
+	}
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return err
+	}
+	task.VisibleAt = visibleAt
+
+	conn.Send("MULTI")
+	conn.Send("ZADD", redisQueueKey, visibleAt.UnixNano(), taskId)
+	data, err = json.Marshal(&task)
+	if err != nil {
+		return err
+	}
+	conn.Send("HSET", redisTasksKey, taskId, data)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (r *RedisBackend) List() ([]*Task, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	ids, err := redis.Strings(conn.Do("ZRANGE", redisQueueKey, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []*Task{}, nil
+	}
+
+	args := redis.Args{}.Add(redisTasksKey)
+	for _, id := range ids {
+		args = args.Add(id)
+	}
+	blobs, err := redis.ByteSlices(conn.Do("HMGET", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]*Task, 0, len(blobs))
+	for _, b := range blobs {
+		if b == nil {
+			continue // task was Ack'd between the ZRANGE and the HMGET
+		}
+		var task Task
+		if err := json.Unmarshal(b, &task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks, nil
+}
+
+// Stop closes the redis connection pool the backend was constructed with.
+func (r *RedisBackend) Stop() error {
+	return r.pool.Close()
+}
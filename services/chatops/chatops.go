@@ -0,0 +1,137 @@
+// Package chatops exposes Slack and Mattermost slash-command webhooks
+// that translate chat commands into calls against the existing scan,
+// plan and target managers, so a scan can be kicked off and checked on
+// without leaving chat.
+package chatops
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	restful "github.com/emicklei/go-restful"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/bearded-web/bearded/pkg/scheduler"
+	"github.com/bearded-web/bearded/services"
+)
+
+// Opts configures the chatops service. Both secrets are optional: a
+// platform whose secret is empty has its webhook endpoint disabled.
+type Opts struct {
+	SlackSigningSecret string
+	MattermostToken    string
+	BaseUrl            string // public base url used to build any links back into Bearded
+}
+
+// Service implements services.ServiceInterface, registering the chatops
+// webhooks on the container.
+type Service struct {
+	*services.Base
+	opts    Opts
+	backend scheduler.Backend
+	mgr     *ProjectMapManager
+
+	mu        sync.Mutex
+	responses map[string]string // scanId -> slack response_url, for the async follow-up
+}
+
+// New returns a chatops Service. backend is the same scheduler.Backend
+// the agent poll endpoint uses; Init registers NotifyScanComplete as a
+// completion listener on it so a scan started from chat gets its
+// asynchronous follow-up without any other service having to know chatops
+// exists.
+func New(base *services.Base, opts Opts, backend scheduler.Backend) *Service {
+	return &Service{
+		Base:      base,
+		opts:      opts,
+		backend:   backend,
+		responses: make(map[string]string),
+	}
+}
+
+// completionNotifier is implemented by scheduler.Instrument's wrapper.
+// Asserting for it here, rather than adding it to scheduler.Backend
+// itself, keeps the interface free of a concern only chatops cares about.
+type completionNotifier interface {
+	AddCompletionListener(scheduler.CompletionListener)
+}
+
+func (s *Service) Init() error {
+	s.mgr = NewProjectMapManager(s.Manager().Copy())
+	if err := s.mgr.Init(); err != nil {
+		return err
+	}
+
+	if notifier, ok := s.backend.(completionNotifier); ok {
+		notifier.AddCompletionListener(s.NotifyScanComplete)
+	} else {
+		logrus.Warn("chatops: scheduler backend does not support completion notifications, /bearded scan will never post its result back to chat")
+	}
+	return nil
+}
+
+func (s *Service) Register(container *restful.Container) {
+	ws := new(restful.WebService)
+	ws.Path("/api/chatops").Produces(restful.MIME_JSON)
+
+	if s.opts.SlackSigningSecret != "" {
+		ws.Route(ws.POST("/slack").To(s.handleSlack).
+			Doc("Slack slash-command webhook for /bearded"))
+	} else {
+		logrus.Warn("chatops: slack-signing-secret is not set, /api/chatops/slack is disabled")
+	}
+
+	if s.opts.MattermostToken != "" {
+		ws.Route(ws.POST("/mattermost").To(s.handleMattermost).
+			Doc("Mattermost slash-command webhook for /bearded"))
+	}
+
+	// Admin endpoint linking a channel to a project, registered regardless
+	// of which chat platform is enabled: relies on the container's usual
+	// session/auth filters, same as every other service route.
+	ws.Route(ws.POST("/mappings").To(s.handleSetMapping).
+		Doc("Link a Slack/Mattermost channel to a Bearded project"))
+
+	container.Add(ws)
+}
+
+// NotifyScanComplete is the scheduler.CompletionListener registered in
+// Init: it fires with a task's ScanId once that task is Ack'd, so the
+// chat command that started the scan gets an asynchronous follow-up
+// instead of the requester having to poll `/bearded status`.
+func (s *Service) NotifyScanComplete(scanId string) {
+	s.mu.Lock()
+	responseUrl, ok := s.responses[scanId]
+	if ok {
+		delete(s.responses, scanId)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	status := "unknown"
+	if bson.IsObjectIdHex(scanId) {
+		if scan, err := s.Manager().Scans().Get(bson.ObjectIdHex(scanId)); err == nil {
+			status = scan.Status
+		}
+	}
+
+	msg := &Message{Text: fmt.Sprintf("scan %s finished: %s", scanId, status)}
+	if err := postResponse(responseUrl, msg); err != nil {
+		logrus.WithField("scanId", scanId).Warnf("chatops: failed to post scan result back to chat: %v", err)
+	}
+}
+
+// rememberResponseUrl associates a scan with the response_url it should
+// be reported back to once NotifyScanComplete fires for it.
+func (s *Service) rememberResponseUrl(scanId, responseUrl string) {
+	if responseUrl == "" {
+		return
+	}
+	s.mu.Lock()
+	s.responses[scanId] = responseUrl
+	s.mu.Unlock()
+}
@@ -0,0 +1,102 @@
+package chatops
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Command is a platform-agnostic slash command, extracted from either a
+// Slack or a Mattermost webhook payload.
+type Command struct {
+	Platform    string // "slack" or "mattermost"
+	TeamId      string
+	ChannelId   string
+	UserId      string
+	Text        string
+	ResponseUrl string
+}
+
+// Message is the JSON body both Slack and Mattermost expect back from a
+// slash command webhook.
+type Message struct {
+	Text         string `json:"text"`
+	ResponseType string `json:"response_type,omitempty"`
+}
+
+func usageMessage() *Message {
+	return &Message{Text: "usage: /bearded scan <target> | /bearded status <scan-id> | /bearded plans"}
+}
+
+// dispatch parses cmd.Text and routes it to the matching sub-command.
+func (s *Service) dispatch(cmd *Command) *Message {
+	fields := strings.Fields(cmd.Text)
+	if len(fields) == 0 {
+		return usageMessage()
+	}
+
+	sub, args := fields[0], fields[1:]
+	switch sub {
+	case "scan":
+		return s.cmdScan(cmd, args)
+	case "status":
+		return s.cmdStatus(cmd, args)
+	case "plans":
+		return s.cmdPlans(cmd, args)
+	default:
+		return usageMessage()
+	}
+}
+
+// cmdScan resolves the project mapped to the requesting channel and
+// starts a scan of args[0] against it.
+func (s *Service) cmdScan(cmd *Command, args []string) *Message {
+	if len(args) != 1 {
+		return &Message{Text: "usage: /bearded scan <target>"}
+	}
+	target := args[0]
+
+	mapping, err := s.mgr.Get(cmd.Platform, cmd.TeamId, cmd.ChannelId)
+	if err != nil {
+		return &Message{Text: "this channel isn't linked to a Bearded project yet, ask an admin to map it first"}
+	}
+
+	t, err := s.Manager().Targets().Create(mapping.ProjectId, target)
+	if err != nil {
+		return &Message{Text: "failed to create target: " + err.Error()}
+	}
+
+	scan, err := s.Manager().Scans().Create(mapping.ProjectId, t.Id)
+	if err != nil {
+		return &Message{Text: "failed to start scan: " + err.Error()}
+	}
+
+	s.rememberResponseUrl(scan.Id.Hex(), cmd.ResponseUrl)
+
+	return &Message{Text: fmt.Sprintf("started scan %s for %s, I'll post here again once it's done", scan.Id.Hex(), target)}
+}
+
+func (s *Service) cmdStatus(cmd *Command, args []string) *Message {
+	if len(args) != 1 || !bson.IsObjectIdHex(args[0]) {
+		return &Message{Text: "usage: /bearded status <scan-id>"}
+	}
+
+	scan, err := s.Manager().Scans().Get(bson.ObjectIdHex(args[0]))
+	if err != nil {
+		return &Message{Text: "scan not found"}
+	}
+	return &Message{Text: fmt.Sprintf("scan %s is %s", scan.Id.Hex(), scan.Status)}
+}
+
+func (s *Service) cmdPlans(cmd *Command, args []string) *Message {
+	plans, err := s.Manager().Plans().List()
+	if err != nil {
+		return &Message{Text: "failed to list plans: " + err.Error()}
+	}
+	names := make([]string, len(plans))
+	for i, p := range plans {
+		names[i] = p.Name
+	}
+	return &Message{Text: "available plans: " + strings.Join(names, ", ")}
+}
@@ -0,0 +1,145 @@
+package chatops
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/Sirupsen/logrus"
+	restful "github.com/emicklei/go-restful"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/bearded-web/bearded/pkg/filters"
+	"github.com/bearded-web/bearded/services/auth"
+)
+
+func (s *Service) handleSlack(req *restful.Request, resp *restful.Response) {
+	body, err := ioutil.ReadAll(req.Request.Body)
+	if err != nil {
+		resp.WriteErrorString(http.StatusBadRequest, "failed to read body")
+		return
+	}
+
+	if !verifySlackSignature(s.opts.SlackSigningSecret, req.Request.Header, body) {
+		filters.EntryFromRequest(req, logrus.StandardLogger()).Warn("chatops: rejected slack webhook with an invalid signature")
+		resp.WriteErrorString(http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		resp.WriteErrorString(http.StatusBadRequest, "failed to parse form")
+		return
+	}
+
+	cmd := &Command{
+		Platform:    "slack",
+		TeamId:      form.Get("team_id"),
+		ChannelId:   form.Get("channel_id"),
+		UserId:      form.Get("user_id"),
+		Text:        form.Get("text"),
+		ResponseUrl: form.Get("response_url"),
+	}
+
+	resp.WriteAsJson(s.dispatch(cmd))
+}
+
+func (s *Service) handleMattermost(req *restful.Request, resp *restful.Response) {
+	if err := req.Request.ParseForm(); err != nil {
+		resp.WriteErrorString(http.StatusBadRequest, "failed to parse form")
+		return
+	}
+	form := req.Request.Form
+
+	if !constantTimeEquals(form.Get("token"), s.opts.MattermostToken) {
+		filters.EntryFromRequest(req, logrus.StandardLogger()).Warn("chatops: rejected mattermost webhook with an invalid token")
+		resp.WriteErrorString(http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	cmd := &Command{
+		Platform:    "mattermost",
+		TeamId:      form.Get("team_id"),
+		ChannelId:   form.Get("channel_id"),
+		UserId:      form.Get("user_id"),
+		Text:        form.Get("text"),
+		ResponseUrl: form.Get("response_url"),
+	}
+
+	resp.WriteAsJson(s.dispatch(cmd))
+}
+
+// mappingRequest is the body handleSetMapping expects to link a channel
+// to a project.
+type mappingRequest struct {
+	Platform  string `json:"platform"`
+	TeamId    string `json:"teamId"`
+	ChannelId string `json:"channelId"`
+	ProjectId string `json:"projectId"`
+}
+
+func (s *Service) handleSetMapping(req *restful.Request, resp *restful.Response) {
+	body := &mappingRequest{}
+	if err := req.ReadEntity(body); err != nil {
+		resp.WriteErrorString(http.StatusBadRequest, "failed to parse body")
+		return
+	}
+	if body.Platform == "" || body.TeamId == "" || body.ChannelId == "" || !bson.IsObjectIdHex(body.ProjectId) {
+		resp.WriteErrorString(http.StatusBadRequest, "platform, teamId, channelId and a valid projectId are required")
+		return
+	}
+	projectId := bson.ObjectIdHex(body.ProjectId)
+
+	entry := filters.EntryFromRequest(req, logrus.StandardLogger())
+
+	u, ok := auth.UserFromRequest(req)
+	if !ok {
+		resp.WriteErrorString(http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	if admin, err := s.Manager().Projects().IsAdmin(projectId, u.Id); err != nil || !admin {
+		entry.WithField("projectId", projectId.Hex()).Warn("chatops: rejected mapping request to a project the caller does not administer")
+		resp.WriteErrorString(http.StatusForbidden, "you must administer projectId to map a channel to it")
+		return
+	}
+
+	// A channel already mapped elsewhere is being repointed: the caller
+	// must also administer the project it's leaving, not just the one
+	// it's joining, or they could hijack another team's channel mapping.
+	if existing, err := s.mgr.Get(body.Platform, body.TeamId, body.ChannelId); err == nil {
+		if admin, err := s.Manager().Projects().IsAdmin(existing.ProjectId, u.Id); err != nil || !admin {
+			entry.WithField("projectId", existing.ProjectId.Hex()).Warn("chatops: rejected remapping a channel away from a project the caller does not administer")
+			resp.WriteErrorString(http.StatusForbidden, "you must also administer the project this channel is currently mapped to")
+			return
+		}
+	}
+
+	mapping := &ProjectMap{
+		Platform:  body.Platform,
+		TeamId:    body.TeamId,
+		ChannelId: body.ChannelId,
+		ProjectId: projectId,
+	}
+	if err := s.mgr.Set(mapping); err != nil {
+		resp.WriteErrorString(http.StatusInternalServerError, "failed to save mapping: "+err.Error())
+		return
+	}
+	resp.WriteHeaderAndEntity(http.StatusOK, mapping)
+}
+
+// postResponse delivers an asynchronous follow-up message to a Slack or
+// Mattermost response_url, used once a scan started from chat finishes.
+func postResponse(responseUrl string, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(responseUrl, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
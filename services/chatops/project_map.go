@@ -0,0 +1,76 @@
+package chatops
+
+import (
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/bearded-web/bearded/pkg/manager"
+	"github.com/bearded-web/bearded/pkg/metrics"
+)
+
+const projectMapCollection = "chatops.project_map"
+
+// ProjectMap binds a Slack or Mattermost channel to a Bearded project, so
+// a bare `/bearded scan <target>` knows which project to file the scan
+// under without the caller spelling it out every time.
+type ProjectMap struct {
+	Id        bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Platform  string        `bson:"platform" json:"platform"` // "slack" or "mattermost"
+	TeamId    string        `bson:"teamId" json:"teamId"`
+	ChannelId string        `bson:"channelId" json:"channelId"`
+	ProjectId bson.ObjectId `bson:"projectId" json:"projectId"`
+}
+
+// ProjectMapManager persists ProjectMap entries in their own collection,
+// the same way other managers persist their entities.
+type ProjectMapManager struct {
+	*manager.Manager
+}
+
+func NewProjectMapManager(mgr *manager.Manager) *ProjectMapManager {
+	return &ProjectMapManager{Manager: mgr}
+}
+
+func (m *ProjectMapManager) Init() error {
+	return m.C(projectMapCollection).EnsureIndex(mgo.Index{
+		Key:    []string{"platform", "teamId", "channelId"},
+		Unique: true,
+	})
+}
+
+// Get returns the project mapped to channelId on platform, or
+// mgo.ErrNotFound if the channel hasn't been linked to a project yet.
+func (m *ProjectMapManager) Get(platform, teamId, channelId string) (*ProjectMap, error) {
+	defer metrics.TimeMongoQuery(projectMapCollection, "find")()
+	mapping := &ProjectMap{}
+	err := m.C(projectMapCollection).Find(bson.M{
+		"platform":  platform,
+		"teamId":    teamId,
+		"channelId": channelId,
+	}).One(mapping)
+	if err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// Set links channelId on platform to projectId, replacing any mapping
+// that already exists for that channel.
+func (m *ProjectMapManager) Set(mapping *ProjectMap) error {
+	defer metrics.TimeMongoQuery(projectMapCollection, "upsert")()
+	if mapping.Id == "" {
+		// Keep the stored mapping's id: mongo refuses a replacement that
+		// changes _id, so a fresh id would fail for an already mapped channel.
+		if existing, err := m.Get(mapping.Platform, mapping.TeamId, mapping.ChannelId); err == nil {
+			mapping.Id = existing.Id
+		} else {
+			mapping.Id = bson.NewObjectId()
+		}
+	}
+	_, err := m.C(projectMapCollection).Upsert(bson.M{
+		"platform":  mapping.Platform,
+		"teamId":    mapping.TeamId,
+		"channelId": mapping.ChannelId,
+	}, mapping)
+	return err
+}
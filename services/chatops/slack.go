@@ -0,0 +1,50 @@
+package chatops
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxSlackClockSkew rejects requests whose timestamp is older than this,
+// so a captured request body/signature pair can't be replayed later.
+const maxSlackClockSkew = 5 * time.Minute
+
+// verifySlackSignature checks the X-Slack-Signature header against the
+// raw request body, per Slack's signing secret scheme:
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackSignature(secret string, header http.Header, body []byte) bool {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sig := header.Get("X-Slack-Signature")
+	if ts == "" || sig == "" {
+		return false
+	}
+
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	if time.Since(time.Unix(seconds, 0)) > maxSlackClockSkew {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(sig)) == 1
+}
+
+// constantTimeEquals compares two tokens without leaking timing
+// information, for the simpler Mattermost shared-token scheme.
+func constantTimeEquals(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
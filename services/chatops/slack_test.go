@@ -0,0 +1,117 @@
+package chatops
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	secret := "shhh"
+	body := []byte("token=abc&team_id=T1")
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-maxSlackClockSkew-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{
+			name: "valid signature",
+			header: http.Header{
+				"X-Slack-Request-Timestamp": []string{now},
+				"X-Slack-Signature":         []string{sign(secret, now, body)},
+			},
+			want: true,
+		},
+		{
+			name: "wrong secret",
+			header: http.Header{
+				"X-Slack-Request-Timestamp": []string{now},
+				"X-Slack-Signature":         []string{sign("other-secret", now, body)},
+			},
+			want: false,
+		},
+		{
+			name: "tampered body is caught because the signature no longer matches",
+			header: http.Header{
+				"X-Slack-Request-Timestamp": []string{now},
+				"X-Slack-Signature":         []string{sign(secret, now, []byte("token=evil"))},
+			},
+			want: false,
+		},
+		{
+			name: "stale timestamp is rejected as a replay",
+			header: http.Header{
+				"X-Slack-Request-Timestamp": []string{stale},
+				"X-Slack-Signature":         []string{sign(secret, stale, body)},
+			},
+			want: false,
+		},
+		{
+			name: "missing timestamp",
+			header: http.Header{
+				"X-Slack-Signature": []string{sign(secret, now, body)},
+			},
+			want: false,
+		},
+		{
+			name: "missing signature",
+			header: http.Header{
+				"X-Slack-Request-Timestamp": []string{now},
+			},
+			want: false,
+		},
+		{
+			name: "non-numeric timestamp",
+			header: http.Header{
+				"X-Slack-Request-Timestamp": []string{"not-a-number"},
+				"X-Slack-Signature":         []string{sign(secret, now, body)},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := verifySlackSignature(secret, tt.header, body)
+			if got != tt.want {
+				t.Errorf("verifySlackSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstantTimeEquals(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{name: "equal", a: "secret-token", b: "secret-token", want: true},
+		{name: "different", a: "secret-token", b: "other-token", want: false},
+		{name: "different length", a: "short", b: "much-longer-token", want: false},
+		{name: "both empty", a: "", b: "", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := constantTimeEquals(tt.a, tt.b)
+			if got != tt.want {
+				t.Errorf("constantTimeEquals(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}